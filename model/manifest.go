@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Generator types understood by RecordCertInfo / RecordKeyInfo / password.GeneratePassword
+const (
+	GeneratorTypePassword      = "Password"
+	GeneratorTypeSSH           = "SSH"
+	GeneratorTypeCACertificate = "CACertificate"
+	GeneratorTypeCertificate   = "Certificate"
+	GeneratorTypeSSHCA         = "SSHCA"
+)
+
+// Generator value types, used to pick which secret entry a configuration
+// variable's generated material is written to
+const (
+	ValueTypePassword       = "password"
+	ValueTypeCertificate    = "certificate"
+	ValueTypePrivateKey     = "private_key"
+	ValueTypePublicKey      = "public_key"
+	ValueTypeFingerprint    = "fingerprint"
+	ValueTypeSSHCertificate = "ssh_certificate"
+)
+
+// Manifest is the root of a role manifest
+type Manifest struct {
+	Roles         Roles                 `yaml:"roles"`
+	Configuration *ConfigurationSection `yaml:"configuration"`
+}
+
+// Roles is a list of roles
+type Roles []*Role
+
+// Role is a single instance group in the manifest
+type Role struct {
+	Name string `yaml:"name"`
+}
+
+// ConfigurationSection holds the configuration variables for the manifest
+type ConfigurationSection struct {
+	Variables []*ConfigurationVariable `yaml:"variables"`
+}
+
+// ConfigurationVariable is a single variable that may have a generator
+// attached to it
+type ConfigurationVariable struct {
+	Name      string                          `yaml:"name"`
+	Generator *ConfigurationVariableGenerator `yaml:"generator,omitempty"`
+}
+
+// ConfigurationVariableGenerator describes how to generate the value for a
+// configuration variable
+type ConfigurationVariableGenerator struct {
+	ID           string   `yaml:"id"`
+	Type         string   `yaml:"type"`
+	ValueType    string   `yaml:"value_type"`
+	SubjectNames []string `yaml:"subject_names,omitempty"`
+	RoleName     string   `yaml:"role_name,omitempty"`
+
+	// Profile selects the certificate usage profile: "server", "client",
+	// "both" (the default), "code-signing", or "ocsp-signing". Expiry is a
+	// Go duration string (e.g. "8760h"); both default to the historical
+	// 30-year, server+client auth cert when unset.
+	Profile string `yaml:"profile,omitempty"`
+	Expiry  string `yaml:"expiry,omitempty"`
+
+	// CAName references the generator ID of the CA that should sign this
+	// certificate. Leaves it to the default CA when empty. A CA generator
+	// may also set this, in which case it is signed as an intermediate CA
+	// by the referenced CA instead of self-signing.
+	CAName string `yaml:"ca_name,omitempty"`
+
+	// SSHCertType selects "host" (the default) or "user" for an SSH
+	// certificate signed by an SSHCA generator.
+	SSHCertType string `yaml:"ssh_cert_type,omitempty"`
+
+	// KeyAlgorithm selects the private key algorithm to generate:
+	// "rsa" (the default), "ecdsa" (certificate generators only), or
+	// "ed25519" (SSH generators only).
+	// KeySize is the key size/curve in bits, where applicable.
+	KeyAlgorithm string `yaml:"key_algorithm,omitempty"`
+	KeySize      int    `yaml:"key_size,omitempty"`
+}
+
+// GetManifest reads and parses a role manifest from reader
+func GetManifest(reader io.Reader) (*Manifest, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Configuration == nil {
+		return nil, fmt.Errorf("'configuration section' not found in manifest")
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,33 @@
+package password
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	glog "log"
+)
+
+// passwordBytes is the number of random bytes used to generate a
+// password, hex-encoded to a 64 character secret value.
+const passwordBytes = 32
+
+// GeneratePassword creates a random password and records it under name in
+// secretData and updateData, unless secretData already has one. It
+// returns true if a new password was generated.
+func GeneratePassword(secretData, updateData map[string][]byte, name string) bool {
+	if len(secretData[name]) > 0 {
+		return false
+	}
+
+	buf := make([]byte, passwordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		glog.Printf("Cannot generate password: %s", err)
+		return false
+	}
+
+	password := make([]byte, hex.EncodedLen(len(buf)))
+	hex.Encode(password, buf)
+
+	secretData[name] = password
+	updateData[name] = password
+	return true
+}
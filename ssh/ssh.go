@@ -0,0 +1,300 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	glog "log"
+	"strings"
+
+	"github.com/SUSE/scf-secret-generator/model"
+	"github.com/SUSE/scf-secret-generator/util"
+	gossh "golang.org/x/crypto/ssh"
+
+	"k8s.io/api/core/v1"
+)
+
+const rsaKeyBits = 4096
+
+// Key contains the information required to generate (or reference) an SSH
+// keypair entry
+type Key struct {
+	PrivateKey  string
+	Fingerprint string
+
+	// KeyAlgorithm selects the key type to generate: "rsa" (the default)
+	// or "ed25519". KeySize is only honoured for "rsa".
+	KeyAlgorithm string
+	KeySize      int
+
+	// PublicKey, if set, is the secret entry to hold this key's public
+	// key in OpenSSH authorized_keys format.
+	PublicKey string
+
+	// Certificate, if set, is the secret entry to hold the signed SSH
+	// certificate blob for this key (ssh-rsa-cert-v01@openssh.com or
+	// ssh-ed25519-cert-v01@openssh.com).
+	Certificate string
+
+	// IsAuthority marks this entry as an SSH CA keypair, analogous to
+	// ssl.CertInfo.IsAuthority.
+	IsAuthority bool
+
+	// CAName is the ID (in the same keys map) of the SSH CA that signs
+	// this entry's Certificate.
+	CAName string
+
+	// RoleName derives this cert's principals, using the same
+	// KUBERNETES_NAMESPACE / KUBE_SERVICE_DOMAIN_SUFFIX templating as
+	// ssl.createCert.
+	RoleName string
+
+	// CertType is "host" (the default) or "user".
+	CertType string
+}
+
+// RecordKeyInfo records SSH key information for later generation
+func RecordKeyInfo(keys map[string]Key, configVar *model.ConfigurationVariable) {
+	key := keys[configVar.Generator.ID]
+
+	switch configVar.Generator.ValueType {
+	case model.ValueTypeFingerprint:
+		key.Fingerprint = configVar.Name
+	case model.ValueTypePrivateKey:
+		key.PrivateKey = configVar.Name
+	case model.ValueTypePublicKey:
+		key.PublicKey = configVar.Name
+	case model.ValueTypeSSHCertificate:
+		key.Certificate = configVar.Name
+	default:
+		glog.Printf("Invalid SSH key generator value_type: %s", configVar.Generator.ValueType)
+	}
+
+	if configVar.Generator.Type == model.GeneratorTypeSSHCA {
+		key.IsAuthority = true
+	}
+	if configVar.Generator.CAName != "" {
+		key.CAName = configVar.Generator.CAName
+	}
+	if configVar.Generator.RoleName != "" {
+		key.RoleName = configVar.Generator.RoleName
+	}
+	if configVar.Generator.SSHCertType != "" {
+		key.CertType = configVar.Generator.SSHCertType
+	}
+	if configVar.Generator.KeyAlgorithm != "" {
+		key.KeyAlgorithm = configVar.Generator.KeyAlgorithm
+	}
+	if configVar.Generator.KeySize != 0 {
+		key.KeySize = configVar.Generator.KeySize
+	}
+
+	keys[configVar.Generator.ID] = key
+}
+
+// GenerateCerts generates every SSH CA keypair in keys, then signs every
+// entry that references one via CAName, producing a private key, public
+// key, and signed certificate secret entry for each. It returns true if
+// anything was created or signed.
+func GenerateCerts(keys map[string]Key, namespace, serviceDomainSuffix string, secrets *v1.Secret) (bool, error) {
+	updated := false
+
+	for id, key := range keys {
+		if !key.IsAuthority {
+			continue
+		}
+		glog.Printf("- SSH CA: %s\n", id)
+		created := len(secrets.Data[util.ConvertNameToKey(key.PrivateKey)]) == 0
+		GenerateKey(secrets, key)
+		updated = updated || created
+	}
+
+	for id, key := range keys {
+		if key.IsAuthority || key.CAName == "" {
+			continue
+		}
+		glog.Printf("- SSH cert: %s (signed by %s)\n", id, key.CAName)
+		signed, err := signCertificate(keys, secrets, id, key, namespace, serviceDomainSuffix)
+		if err != nil {
+			return false, err
+		}
+		updated = updated || signed
+	}
+
+	for id, key := range keys {
+		if key.IsAuthority || key.CAName != "" {
+			continue
+		}
+		glog.Printf("- SSH key: %s\n", id)
+		created := len(secrets.Data[util.ConvertNameToKey(key.PrivateKey)]) == 0
+		GenerateKey(secrets, key)
+		updated = updated || created
+	}
+
+	return updated, nil
+}
+
+// signCertificate ensures key's own keypair exists, then has it signed by
+// the SSH CA named in key.CAName.
+func signCertificate(keys map[string]Key, secrets *v1.Secret, id string, key Key, namespace, serviceDomainSuffix string) (bool, error) {
+	certName := util.ConvertNameToKey(key.Certificate)
+	if len(secrets.Data[certName]) > 0 {
+		return false, nil
+	}
+
+	ca, ok := keys[key.CAName]
+	if !ok || !ca.IsAuthority {
+		return false, fmt.Errorf("SSH CA %s not found", key.CAName)
+	}
+
+	if key.RoleName == "" {
+		return false, fmt.Errorf("SSH cert %s has no role_name: refusing to sign a certificate valid for any principal", id)
+	}
+
+	GenerateKey(secrets, key)
+
+	caSigner, err := gossh.ParsePrivateKey(secrets.Data[util.ConvertNameToKey(ca.PrivateKey)])
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse SSH CA private key: %s", err)
+	}
+
+	hostSigner, err := gossh.ParsePrivateKey(secrets.Data[util.ConvertNameToKey(key.PrivateKey)])
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse SSH private key: %s", err)
+	}
+
+	certType := uint32(gossh.HostCert)
+	if key.CertType == "user" {
+		certType = gossh.UserCert
+	}
+
+	cert := &gossh.Certificate{
+		Key:             hostSigner.PublicKey(),
+		CertType:        certType,
+		KeyId:           id,
+		ValidPrincipals: principals(key.RoleName, namespace, serviceDomainSuffix),
+		ValidAfter:      0,
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		return false, fmt.Errorf("Cannot sign SSH certificate: %s", err)
+	}
+
+	secrets.Data[certName] = gossh.MarshalAuthorizedKey(cert)
+	return true, nil
+}
+
+// principals derives the SSH certificate principals for a role, mirroring
+// the host/cluster names ssl.createCert adds as certificate SANs.
+func principals(roleName, namespace, serviceDomainSuffix string) []string {
+	if roleName == "" {
+		return nil
+	}
+	return []string{
+		roleName,
+		fmt.Sprintf("%s.%s.svc", roleName, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", roleName, namespace),
+		fmt.Sprintf("%s.%s", roleName, serviceDomainSuffix),
+	}
+}
+
+// GenerateKey creates an SSH private key and fingerprint, unless one
+// already exists under the given secret entries
+func GenerateKey(secrets *v1.Secret, key Key) {
+	privateKeyName := util.ConvertNameToKey(key.PrivateKey)
+	fingerprintName := util.ConvertNameToKey(key.Fingerprint)
+
+	if len(secrets.Data[privateKeyName]) > 0 {
+		return
+	}
+
+	var (
+		privateKeyPEM []byte
+		signer        gossh.Signer
+		err           error
+	)
+
+	switch key.KeyAlgorithm {
+	case "ed25519":
+		privateKeyPEM, signer, err = generateEd25519Key()
+	case "", "rsa":
+		privateKeyPEM, signer, err = generateRSAKey(key.KeySize)
+	default:
+		err = fmt.Errorf("unknown SSH key algorithm: %s", key.KeyAlgorithm)
+	}
+	if err != nil {
+		glog.Printf("Cannot generate SSH key: %s", err)
+		return
+	}
+
+	secrets.Data[privateKeyName] = privateKeyPEM
+	secrets.Data[fingerprintName] = []byte(fingerprint(signer.PublicKey(), key.KeyAlgorithm))
+
+	if key.PublicKey != "" {
+		secrets.Data[util.ConvertNameToKey(key.PublicKey)] = gossh.MarshalAuthorizedKey(signer.PublicKey())
+	}
+}
+
+func generateRSAKey(size int) ([]byte, gossh.Signer, error) {
+	if size == 0 {
+		size = rsaKeyBits
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKeyPEM, signer, nil
+}
+
+func generateEd25519Key() ([]byte, gossh.Signer, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: pkcs8,
+	})
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKeyPEM, signer, nil
+}
+
+// fingerprint formats a public key's fingerprint. RSA keys use the
+// traditional MD5 colon-hex form; ed25519 keys use the SHA256 form, since
+// MD5 colon-hex is conventionally an RSA-only representation.
+func fingerprint(pub gossh.PublicKey, algorithm string) string {
+	if algorithm == "ed25519" {
+		return gossh.FingerprintSHA256(pub)
+	}
+
+	sum := md5.Sum(pub.Marshal())
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -5,10 +5,13 @@ package ssh
 // how to test generating the keys?
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/SUSE/scf-secret-generator/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
 	"k8s.io/api/core/v1"
 )
 
@@ -93,3 +96,181 @@ func TestRecordingPrivateCreatesKey(t *testing.T) {
 
 	assert.Equal(t, "PRIVATE_KEY_NAME", keys["foo"].PrivateKey)
 }
+
+func TestRecordingKeyAlgorithmCreatesKey(t *testing.T) {
+	t.Parallel()
+
+	keys := make(map[string]Key)
+
+	configVar := &model.ConfigurationVariable{
+		Name: "PRIVATE_KEY_NAME",
+	}
+	configVar.Generator = &model.ConfigurationVariableGenerator{
+		ID:           "foo",
+		ValueType:    model.ValueTypePrivateKey,
+		KeyAlgorithm: "ed25519",
+	}
+
+	RecordKeyInfo(keys, configVar)
+
+	assert.Equal(t, "ed25519", keys["foo"].KeyAlgorithm)
+}
+
+// GenerateKey ed25519 tests
+
+func TestNewEd25519KeyIsCreated(t *testing.T) {
+	t.Parallel()
+
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	key := Key{
+		PrivateKey:   "foo",
+		Fingerprint:  "bar",
+		KeyAlgorithm: "ed25519",
+	}
+
+	GenerateKey(secrets, key)
+
+	assert.Contains(t, string(secrets.Data["foo"]), "BEGIN PRIVATE KEY")
+	assert.Contains(t, string(secrets.Data["bar"]), "SHA256:")
+}
+
+// RecordKeyInfo SSH CA tests
+
+func TestRecordingSSHCATypeMarksAuthority(t *testing.T) {
+	t.Parallel()
+
+	keys := make(map[string]Key)
+
+	configVar := &model.ConfigurationVariable{
+		Name: "PRIVATE_KEY_NAME",
+	}
+	configVar.Generator = &model.ConfigurationVariableGenerator{
+		ID:        "ca",
+		Type:      model.GeneratorTypeSSHCA,
+		ValueType: model.ValueTypePrivateKey,
+	}
+
+	RecordKeyInfo(keys, configVar)
+
+	assert.True(t, keys["ca"].IsAuthority)
+}
+
+func TestRecordingCANameAndRoleName(t *testing.T) {
+	t.Parallel()
+
+	keys := make(map[string]Key)
+
+	configVar := &model.ConfigurationVariable{
+		Name: "CERT_NAME",
+	}
+	configVar.Generator = &model.ConfigurationVariableGenerator{
+		ID:        "host",
+		ValueType: model.ValueTypeSSHCertificate,
+		CAName:    "ca",
+		RoleName:  "api",
+	}
+
+	RecordKeyInfo(keys, configVar)
+
+	assert.Equal(t, "CERT_NAME", keys["host"].Certificate)
+	assert.Equal(t, "ca", keys["host"].CAName)
+	assert.Equal(t, "api", keys["host"].RoleName)
+}
+
+// GenerateCerts tests
+
+func TestGenerateCertsSignsHostCertificate(t *testing.T) {
+	t.Parallel()
+
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	keys := map[string]Key{
+		"ca": {
+			PrivateKey:  "ca-key",
+			Fingerprint: "ca-fingerprint",
+			IsAuthority: true,
+		},
+		"host": {
+			PrivateKey:  "host-key",
+			Fingerprint: "host-fingerprint",
+			PublicKey:   "host-pub",
+			Certificate: "host-cert",
+			CAName:      "ca",
+			RoleName:    "api",
+		},
+	}
+
+	updated, err := GenerateCerts(keys, "ns", "svc.example.com", secrets)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	assert.NotEmpty(t, secrets.Data["ca-key"])
+	assert.NotEmpty(t, secrets.Data["host-key"])
+	assert.NotEmpty(t, secrets.Data["host-pub"])
+	assert.NotEmpty(t, secrets.Data["host-cert"])
+
+	pubKey, _, _, _, err := gossh.ParseAuthorizedKey(secrets.Data["host-cert"])
+	require.NoError(t, err)
+	cert, ok := pubKey.(*gossh.Certificate)
+	require.True(t, ok)
+	assert.Equal(t, uint32(gossh.HostCert), cert.CertType)
+	assert.Contains(t, cert.ValidPrincipals, "api")
+	assert.Contains(t, cert.ValidPrincipals, "api.svc.example.com")
+
+	caSigner, err := gossh.ParsePrivateKey(secrets.Data["ca-key"])
+	require.NoError(t, err)
+
+	checker := &gossh.CertChecker{
+		IsHostAuthority: func(auth gossh.PublicKey, address string) bool {
+			return bytes.Equal(auth.Marshal(), caSigner.PublicKey().Marshal())
+		},
+	}
+	require.NoError(t, checker.CheckCert("api", cert))
+}
+
+func TestGenerateCertsRejectsMissingRoleName(t *testing.T) {
+	t.Parallel()
+
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	keys := map[string]Key{
+		"ca": {
+			PrivateKey:  "ca-key",
+			Fingerprint: "ca-fingerprint",
+			IsAuthority: true,
+		},
+		"host": {
+			PrivateKey:  "host-key",
+			Fingerprint: "host-fingerprint",
+			PublicKey:   "host-pub",
+			Certificate: "host-cert",
+			CAName:      "ca",
+		},
+	}
+
+	_, err := GenerateCerts(keys, "ns", "svc.example.com", secrets)
+	require.Error(t, err, "a certificate with no role_name would carry an empty ValidPrincipals, trusted for any principal")
+	assert.Empty(t, secrets.Data["host-cert"])
+}
+
+func TestGenerateCertsGeneratesPlainKey(t *testing.T) {
+	t.Parallel()
+
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	keys := map[string]Key{
+		"plain": {
+			PrivateKey:  "plain-key",
+			Fingerprint: "plain-fingerprint",
+		},
+	}
+
+	updated, err := GenerateCerts(keys, "ns", "svc.example.com", secrets)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	assert.NotEmpty(t, secrets.Data["plain-key"])
+	assert.NotEmpty(t, secrets.Data["plain-fingerprint"])
+	assert.Empty(t, secrets.Data["plain-cert"])
+}
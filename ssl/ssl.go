@@ -6,6 +6,7 @@ import (
 	"html/template"
 	glog "log"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/SUSE/scf-secret-generator/model"
@@ -21,7 +22,30 @@ import (
 	"k8s.io/api/core/v1"
 )
 
-const defaultCA = "cacert"
+const (
+	defaultCA = "cacert"
+
+	// renewBeforeEnvVar overrides how long before expiry a certificate is
+	// renewed, expressed as a Go duration string (e.g. "720h").
+	renewBeforeEnvVar = "SSL_CERT_RENEW_BEFORE"
+
+	// defaultRenewBefore is used when renewBeforeEnvVar is unset or invalid.
+	defaultRenewBefore = 30 * 24 * time.Hour
+
+	// PreviousSuffix is appended to a certificate's secret key to record
+	// the cert it is replacing, so consumers can trust both during the
+	// rollover window. Exported so store.Store can round-trip it too.
+	PreviousSuffix = "-previous"
+)
+
+// Cert usage profiles selectable via the profile generator attribute
+const (
+	profileServer      = "server"
+	profileClient      = "client"
+	profileBoth        = "both"
+	profileCodeSigning = "code-signing"
+	profileOCSPSigning = "ocsp-signing"
+)
 
 // CertInfo contains all the information required to generate an SSL cert
 type CertInfo struct {
@@ -32,6 +56,22 @@ type CertInfo struct {
 	SubjectNames []string
 	RoleName     string
 
+	// CAName is the ID of the CA that signs this cert. Empty means the
+	// default CA. A CA entry may also set this, in which case it is
+	// signed as an intermediate CA by the referenced CA.
+	CAName string
+
+	// Profile selects the cert usage profile (see profile* constants
+	// above); the zero value is profileBoth. Expiry overrides the
+	// default 30-year validity period.
+	Profile string
+	Expiry  time.Duration
+
+	// KeyAlgorithm and KeySize select the private key algorithm to
+	// generate ("rsa" by default, or "ecdsa") and its size/curve.
+	KeyAlgorithm string
+	KeySize      int
+
 	Certificate []byte
 	PrivateKey  []byte
 }
@@ -56,21 +96,50 @@ func RecordCertInfo(certInfo map[string]CertInfo, configVar *model.Configuration
 	if configVar.Generator.RoleName != "" {
 		info.RoleName = configVar.Generator.RoleName
 	}
+	if configVar.Generator.CAName != "" {
+		info.CAName = configVar.Generator.CAName
+	}
+	if configVar.Generator.Profile != "" {
+		info.Profile = configVar.Generator.Profile
+	}
+	if configVar.Generator.Expiry != "" {
+		expiry, err := time.ParseDuration(configVar.Generator.Expiry)
+		if err != nil {
+			glog.Printf("Invalid expiry %q for certificate %s: %s", configVar.Generator.Expiry, configVar.Generator.ID, err)
+		} else {
+			info.Expiry = expiry
+		}
+	}
+	if configVar.Generator.KeyAlgorithm != "" {
+		info.KeyAlgorithm = configVar.Generator.KeyAlgorithm
+	}
+	if configVar.Generator.KeySize != 0 {
+		info.KeySize = configVar.Generator.KeySize
+	}
 	certInfo[configVar.Generator.ID] = info
 }
 
-// GenerateCerts creates an SSL cert and private key
-func GenerateCerts(certInfo map[string]CertInfo, namespace, domain, serviceDomainSuffix string, secrets *v1.Secret) error {
-	// generate all the CAs first because they are needed to sign the certs
-	for id, info := range certInfo {
-		if !info.IsAuthority {
-			continue
-		}
+// GenerateCerts creates an SSL cert and private key for every entry in
+// certInfo, renewing any certificate that has fallen within the renewal
+// threshold of its expiry date. It returns true if any CA or certificate
+// was created or renewed, so callers can trigger a rolling restart.
+func GenerateCerts(certInfo map[string]CertInfo, namespace, domain, serviceDomainSuffix string, secrets *v1.Secret) (bool, error) {
+	renewBefore := renewBeforeThreshold()
+	updated := false
+
+	// generate all the CAs first, in dependency order, because they are
+	// needed to sign the certs (and, for intermediates, each other)
+	caOrder, err := orderCAs(certInfo)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range caOrder {
 		glog.Printf("- SSL CA: %s\n", id)
-		err := createCA(certInfo, secrets, id)
+		caUpdated, err := createCA(certInfo, secrets, id, renewBefore)
 		if err != nil {
-			return err
+			return false, err
 		}
+		updated = updated || caUpdated
 	}
 	for id, info := range certInfo {
 		if info.IsAuthority {
@@ -80,19 +149,122 @@ func GenerateCerts(certInfo map[string]CertInfo, namespace, domain, serviceDomai
 		if len(info.SubjectNames) == 0 && info.RoleName == "" {
 			fmt.Fprintf(os.Stderr, "Warning: certificate %s has no names\n", info.CertificateName)
 		}
-		err := createCert(certInfo, namespace, domain, serviceDomainSuffix, secrets, id)
+		certUpdated, err := createCert(certInfo, namespace, domain, serviceDomainSuffix, secrets, id, renewBefore)
 		if err != nil {
-			return err
+			return false, err
 		}
+		updated = updated || certUpdated
+	}
+	return updated, nil
+}
+
+// renewBeforeThreshold returns the configured renewal threshold, falling
+// back to defaultRenewBefore if SSL_CERT_RENEW_BEFORE is unset or invalid.
+func renewBeforeThreshold() time.Duration {
+	value := os.Getenv(renewBeforeEnvVar)
+	if value == "" {
+		return defaultRenewBefore
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		glog.Printf("Invalid %s value %q, falling back to default of %s", renewBeforeEnvVar, value, defaultRenewBefore)
+		return defaultRenewBefore
 	}
-	return nil
+	return d
 }
 
-func rsaKeyRequest() *csr.BasicKeyRequest {
-	return &csr.BasicKeyRequest{A: "rsa", S: 4096}
+// needsRenewal reports whether certPEM expires within renewBefore.
+func needsRenewal(certPEM []byte, renewBefore time.Duration) (bool, error) {
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse certificate: %s", err)
+	}
+	return time.Until(cert.NotAfter) < renewBefore, nil
 }
 
-func createCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string) error {
+// orderCAs returns the IDs of every CA in certInfo, ordered so that an
+// intermediate CA (one whose CAName points at another CA) always comes
+// after the CA that signs it. It rejects CAName references to unknown or
+// non-CA entries, and circular CA chains.
+func orderCAs(certInfo map[string]CertInfo) ([]string, error) {
+	var ids []string
+	for id, info := range certInfo {
+		if info.IsAuthority {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var order []string
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular CA reference involving %s", id)
+		}
+		state[id] = visiting
+
+		info := certInfo[id]
+		if info.CAName != "" {
+			parent, ok := certInfo[info.CAName]
+			if !ok {
+				return fmt.Errorf("CA %s references unknown CA %s", id, info.CAName)
+			}
+			if !parent.IsAuthority {
+				return fmt.Errorf("CA %s references %s, which is not a CA", id, info.CAName)
+			}
+			if err := visit(info.CAName); err != nil {
+				return err
+			}
+		}
+
+		state[id] = visited
+		order = append(order, id)
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func rsaKeyRequest() *csr.KeyRequest {
+	return &csr.KeyRequest{A: "rsa", S: 4096}
+}
+
+// keyRequest builds the key request for the given algorithm, falling back
+// to 4096-bit RSA when algorithm is empty.
+func keyRequest(algorithm string, size int) *csr.KeyRequest {
+	switch algorithm {
+	case "ecdsa":
+		if size == 0 {
+			size = 521
+		}
+		return &csr.KeyRequest{A: "ecdsa", S: size}
+	case "", "rsa":
+		if size == 0 {
+			size = 4096
+		}
+		return &csr.KeyRequest{A: "rsa", S: size}
+	default:
+		glog.Printf("Unknown key algorithm %q, falling back to rsa", algorithm)
+		return rsaKeyRequest()
+	}
+}
+
+func createCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string, renewBefore time.Duration) (bool, error) {
 	var err error
 	info := certInfo[id]
 
@@ -100,25 +272,225 @@ func createCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string) error
 		// fetch CA from secrets because we may need it to sign new certs
 		info.PrivateKey = secrets.Data[info.PrivateKeyName]
 		info.Certificate = secrets.Data[info.CertificateName]
-		certInfo[id] = info
-		return nil
+
+		renew, err := needsRenewal(info.Certificate, renewBefore)
+		if err != nil {
+			return false, err
+		}
+		if !renew {
+			certInfo[id] = info
+			return false, nil
+		}
+		glog.Printf("  CA %s is within %s of expiry, renewing\n", id, renewBefore)
+		if info.CAName != "" {
+			return renewIntermediateCA(certInfo, secrets, id, info)
+		}
+		return renewCA(certInfo, secrets, id, info)
+	}
+
+	if info.CAName != "" {
+		return createIntermediateCA(certInfo, secrets, id, info)
 	}
 
+	expiry := info.Expiry
+	if expiry == 0 {
+		expiry = 262800 * time.Hour // 30 years
+	}
 	req := &csr.CertificateRequest{
-		CA:         &csr.CAConfig{Expiry: "262800h"}, // 30 years
+		CA:         &csr.CAConfig{Expiry: expiry.String()},
 		CN:         "SCF CA",
-		KeyRequest: rsaKeyRequest(),
+		KeyRequest: keyRequest(info.KeyAlgorithm, info.KeySize),
 	}
 	info.Certificate, _, info.PrivateKey, err = initca.New(req)
 	if err != nil {
-		return fmt.Errorf("Cannot create CA: %s", err)
+		return false, fmt.Errorf("Cannot create CA: %s", err)
+	}
+
+	secrets.Data[info.PrivateKeyName] = info.PrivateKey
+	secrets.Data[info.CertificateName] = info.Certificate
+
+	certInfo[id] = info
+	return true, nil
+}
+
+// createIntermediateCA generates a CA keypair and has it signed by the CA
+// named in info.CAName, rather than self-signing, so that certs issued by
+// the intermediate chain back to that parent.
+func createIntermediateCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string, info CertInfo) (bool, error) {
+	parent := certInfo[info.CAName]
+	if len(parent.PrivateKey) == 0 || len(parent.Certificate) == 0 {
+		return false, fmt.Errorf("CA %s not found", info.CAName)
+	}
+
+	req := &csr.CertificateRequest{
+		CN:         fmt.Sprintf("SCF CA: %s", id),
+		KeyRequest: keyRequest(info.KeyAlgorithm, info.KeySize),
+	}
+
+	g := &csr.Generator{Validator: genkey.Validator}
+	signingReq, privateKey, err := g.ProcessRequest(req)
+	if err != nil {
+		return false, fmt.Errorf("Cannot generate intermediate CA: %s", err)
+	}
+
+	parentCert, err := helpers.ParseCertificatePEM(parent.Certificate)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA cert: %s", err)
+	}
+	parentKey, err := helpers.ParsePrivateKeyPEM(parent.PrivateKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA private key: %s", err)
 	}
 
+	expiry := info.Expiry
+	if expiry == 0 {
+		expiry = 262800 * time.Hour // 30 years
+	}
+	signingProfile := &config.SigningProfile{
+		Usage:        []string{"cert sign", "crl sign"},
+		Expiry:       expiry,
+		ExpiryString: expiry.String(),
+		CAConstraint: config.CAConstraint{IsCA: true},
+	}
+	policy := &config.Signing{
+		Profiles: map[string]*config.SigningProfile{},
+		Default:  signingProfile,
+	}
+
+	s, err := local.NewSigner(parentKey, parentCert, signer.DefaultSigAlgo(parentKey), policy)
+	if err != nil {
+		return false, fmt.Errorf("Cannot create signer: %s", err)
+	}
+
+	cert, err := s.Sign(signer.SignRequest{Request: string(signingReq)})
+	if err != nil {
+		return false, fmt.Errorf("Failed to sign intermediate CA: %s", err)
+	}
+
+	info.PrivateKey = privateKey
+	info.Certificate = cert
 	secrets.Data[info.PrivateKeyName] = info.PrivateKey
 	secrets.Data[info.CertificateName] = info.Certificate
 
 	certInfo[id] = info
-	return nil
+	return true, nil
+}
+
+// renewCA rolls a CA's certificate forward while preserving its existing
+// private key, so certs already signed by it remain valid. The replaced
+// certificate is kept under a "-previous" secret entry for the overlap
+// window.
+func renewCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string, info CertInfo) (bool, error) {
+	caKey, err := helpers.ParsePrivateKeyPEM(info.PrivateKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA private key: %s", err)
+	}
+
+	expiry := info.Expiry
+	if expiry == 0 {
+		expiry = 262800 * time.Hour // 30 years
+	}
+	req := &csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: expiry.String()},
+		CN:         "SCF CA",
+		KeyRequest: keyRequest(info.KeyAlgorithm, info.KeySize),
+	}
+	newCert, _, err := initca.NewFromSigner(req, caKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot renew CA: %s", err)
+	}
+
+	secrets.Data[info.CertificateName+PreviousSuffix] = info.Certificate
+	info.Certificate = newCert
+	secrets.Data[info.CertificateName] = info.Certificate
+
+	certInfo[id] = info
+	return true, nil
+}
+
+// renewIntermediateCA rolls an intermediate CA's certificate forward while
+// preserving its existing private key, re-signing it through its parent
+// CA rather than self-signing, mirroring createIntermediateCA. The
+// replaced certificate is kept under a "-previous" secret entry for the
+// overlap window.
+func renewIntermediateCA(certInfo map[string]CertInfo, secrets *v1.Secret, id string, info CertInfo) (bool, error) {
+	parent := certInfo[info.CAName]
+	if len(parent.PrivateKey) == 0 || len(parent.Certificate) == 0 {
+		return false, fmt.Errorf("CA %s not found", info.CAName)
+	}
+
+	caKey, err := helpers.ParsePrivateKeyPEM(info.PrivateKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA private key: %s", err)
+	}
+
+	req := &csr.CertificateRequest{
+		CN:         fmt.Sprintf("SCF CA: %s", id),
+		KeyRequest: keyRequest(info.KeyAlgorithm, info.KeySize),
+	}
+	signingReq, err := csr.Generate(caKey, req)
+	if err != nil {
+		return false, fmt.Errorf("Cannot renew intermediate CA: %s", err)
+	}
+
+	parentCert, err := helpers.ParseCertificatePEM(parent.Certificate)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA cert: %s", err)
+	}
+	parentKey, err := helpers.ParsePrivateKeyPEM(parent.PrivateKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot parse CA private key: %s", err)
+	}
+
+	expiry := info.Expiry
+	if expiry == 0 {
+		expiry = 262800 * time.Hour // 30 years
+	}
+	signingProfile := &config.SigningProfile{
+		Usage:        []string{"cert sign", "crl sign"},
+		Expiry:       expiry,
+		ExpiryString: expiry.String(),
+		CAConstraint: config.CAConstraint{IsCA: true},
+	}
+	policy := &config.Signing{
+		Profiles: map[string]*config.SigningProfile{},
+		Default:  signingProfile,
+	}
+
+	s, err := local.NewSigner(parentKey, parentCert, signer.DefaultSigAlgo(parentKey), policy)
+	if err != nil {
+		return false, fmt.Errorf("Cannot create signer: %s", err)
+	}
+
+	cert, err := s.Sign(signer.SignRequest{Request: string(signingReq)})
+	if err != nil {
+		return false, fmt.Errorf("Failed to sign intermediate CA: %s", err)
+	}
+
+	secrets.Data[info.CertificateName+PreviousSuffix] = info.Certificate
+	info.Certificate = cert
+	secrets.Data[info.CertificateName] = info.Certificate
+
+	certInfo[id] = info
+	return true, nil
+}
+
+// usageForProfile maps a cert usage profile to the cfssl key usages it
+// grants. An unrecognized or empty profile is treated as profileBoth, the
+// historical default.
+func usageForProfile(profile string) []string {
+	switch profile {
+	case profileServer:
+		return []string{"server auth"}
+	case profileClient:
+		return []string{"client auth"}
+	case profileCodeSigning:
+		return []string{"code signing"}
+	case profileOCSPSigning:
+		return []string{"ocsp signing"}
+	default:
+		return []string{"server auth", "client auth"}
+	}
 }
 
 func addHost(req *csr.CertificateRequest, wildcard bool, name string) {
@@ -128,42 +500,64 @@ func addHost(req *csr.CertificateRequest, wildcard bool, name string) {
 	}
 }
 
-func createCert(certInfo map[string]CertInfo, namespace, domain, serviceDomainSuffix string, secrets *v1.Secret, id string) error {
+func createCert(certInfo map[string]CertInfo, namespace, domain, serviceDomainSuffix string, secrets *v1.Secret, id string, renewBefore time.Duration) (bool, error) {
 	var err error
 	info := certInfo[id]
 
+	var previousCert []byte
 	if len(secrets.Data[info.PrivateKeyName]) > 0 {
-		return nil
+		renew, err := needsRenewal(secrets.Data[info.CertificateName], renewBefore)
+		if err != nil {
+			return false, err
+		}
+		if !renew {
+			return false, nil
+		}
+		glog.Printf("  Certificate %s is within %s of expiry, renewing\n", id, renewBefore)
+		previousCert = secrets.Data[info.CertificateName]
 	}
 
-	// XXX Add support for multiple CAs
-	caInfo := certInfo[defaultCA]
+	caName := info.CAName
+	if caName == "" {
+		caName = defaultCA
+	}
+	caInfo, ok := certInfo[caName]
+	if !ok || !caInfo.IsAuthority {
+		return false, fmt.Errorf("CA %s not found", caName)
+	}
 	if len(caInfo.PrivateKey) == 0 || len(caInfo.Certificate) == 0 {
-		return fmt.Errorf("CA %s not found", defaultCA)
+		return false, fmt.Errorf("CA %s not found", caName)
 	}
 
-	req := &csr.CertificateRequest{KeyRequest: rsaKeyRequest()}
+	req := &csr.CertificateRequest{KeyRequest: keyRequest(info.KeyAlgorithm, info.KeySize)}
 
-	if info.RoleName != "" {
-		addHost(req, true, info.RoleName)
-		addHost(req, true, fmt.Sprintf("%s.%s.svc", info.RoleName, namespace))
-		addHost(req, true, fmt.Sprintf("%s.%s.svc.cluster.local", info.RoleName, namespace))
+	// Client-only certs authenticate a caller to a server rather than a
+	// server to its callers, so the stateful-set/self-clustering SAN
+	// wildcarding below doesn't apply to them.
+	wildcard := info.Profile != profileClient
 
-		// Generate wildcard certs for stateful sets for self-clustering roles
-		// We do this instead of having a bunch of subject alt names so that the
-		// certs can work correctly if we scale the cluster post-deployment.
-		prefix := fmt.Sprintf("*.%s-set", info.RoleName)
-		addHost(req, false, prefix)
-		addHost(req, false, fmt.Sprintf("%s.%s.svc", prefix, namespace))
-		addHost(req, false, fmt.Sprintf("%s.%s.svc.cluster.local", prefix, namespace))
+	if info.RoleName != "" {
+		addHost(req, wildcard, info.RoleName)
+		addHost(req, wildcard, fmt.Sprintf("%s.%s.svc", info.RoleName, namespace))
+		addHost(req, wildcard, fmt.Sprintf("%s.%s.svc.cluster.local", info.RoleName, namespace))
+
+		if wildcard {
+			// Generate wildcard certs for stateful sets for self-clustering roles
+			// We do this instead of having a bunch of subject alt names so that the
+			// certs can work correctly if we scale the cluster post-deployment.
+			prefix := fmt.Sprintf("*.%s-set", info.RoleName)
+			addHost(req, false, prefix)
+			addHost(req, false, fmt.Sprintf("%s.%s.svc", prefix, namespace))
+			addHost(req, false, fmt.Sprintf("%s.%s.svc.cluster.local", prefix, namespace))
+		}
 
-		addHost(req, true, fmt.Sprintf("%s.%s", info.RoleName, serviceDomainSuffix))
+		addHost(req, wildcard, fmt.Sprintf("%s.%s", info.RoleName, serviceDomainSuffix))
 	}
 
 	for _, name := range info.SubjectNames {
 		t, err := template.New("").Parse(name)
 		if err != nil {
-			return fmt.Errorf("Can't parse subject name '%s' for certificate '%s': %s", name, id, err)
+			return false, fmt.Errorf("Can't parse subject name '%s' for certificate '%s': %s", name, id, err)
 		}
 		buf := &bytes.Buffer{}
 		mapping := map[string]string{
@@ -173,7 +567,7 @@ func createCert(certInfo map[string]CertInfo, namespace, domain, serviceDomainSu
 		}
 		err = t.Execute(buf, mapping)
 		if err != nil {
-			return err
+			return false, err
 		}
 		addHost(req, false, buf.String())
 	}
@@ -187,22 +581,26 @@ func createCert(certInfo map[string]CertInfo, namespace, domain, serviceDomainSu
 	g := &csr.Generator{Validator: genkey.Validator}
 	signingReq, info.PrivateKey, err = g.ProcessRequest(req)
 	if err != nil {
-		return fmt.Errorf("Cannot generate cert: %s", err)
+		return false, fmt.Errorf("Cannot generate cert: %s", err)
 	}
 
 	caCert, err := helpers.ParseCertificatePEM(caInfo.Certificate)
 	if err != nil {
-		return fmt.Errorf("Cannot parse CA cert: %s", err)
+		return false, fmt.Errorf("Cannot parse CA cert: %s", err)
 	}
 	caKey, err := helpers.ParsePrivateKeyPEM(caInfo.PrivateKey)
 	if err != nil {
-		return fmt.Errorf("Cannot parse CA private key: %s", err)
+		return false, fmt.Errorf("Cannot parse CA private key: %s", err)
 	}
 
+	expiry := info.Expiry
+	if expiry == 0 {
+		expiry = 262800 * time.Hour // 30 years
+	}
 	signingProfile := &config.SigningProfile{
-		Usage:        []string{"server auth", "client auth"},
-		Expiry:       262800 * time.Hour, // 30 years
-		ExpiryString: "262800h",          // 30 years
+		Usage:        usageForProfile(info.Profile),
+		Expiry:       expiry,
+		ExpiryString: expiry.String(),
 	}
 	policy := &config.Signing{
 		Profiles: map[string]*config.SigningProfile{},
@@ -211,29 +609,33 @@ func createCert(certInfo map[string]CertInfo, namespace, domain, serviceDomainSu
 
 	s, err := local.NewSigner(caKey, caCert, signer.DefaultSigAlgo(caKey), policy)
 	if err != nil {
-		return fmt.Errorf("Cannot create signer: %s", err)
+		return false, fmt.Errorf("Cannot create signer: %s", err)
 	}
 
 	info.Certificate, err = s.Sign(signer.SignRequest{Request: string(signingReq)})
 	if err != nil {
-		return fmt.Errorf("Failed to sign cert: %s", err)
+		return false, fmt.Errorf("Failed to sign cert: %s", err)
 	}
 
 	if len(info.PrivateKeyName) == 0 {
-		return fmt.Errorf("Certificate %s created with empty private key name", id)
+		return false, fmt.Errorf("Certificate %s created with empty private key name", id)
 	}
 	if len(info.PrivateKey) == 0 {
-		return fmt.Errorf("Certificate %s created with empty private key", id)
+		return false, fmt.Errorf("Certificate %s created with empty private key", id)
 	}
 	if len(info.CertificateName) == 0 {
-		return fmt.Errorf("Certificate %s created with empty certificate name", id)
+		return false, fmt.Errorf("Certificate %s created with empty certificate name", id)
 	}
 	if len(info.Certificate) == 0 {
-		return fmt.Errorf("Certificate %s created with empty certificate", id)
+		return false, fmt.Errorf("Certificate %s created with empty certificate", id)
+	}
+
+	if len(previousCert) > 0 {
+		secrets.Data[info.CertificateName+PreviousSuffix] = previousCert
 	}
 	secrets.Data[info.PrivateKeyName] = info.PrivateKey
 	secrets.Data[info.CertificateName] = info.Certificate
 	certInfo[id] = info
 
-	return nil
+	return true, nil
 }
@@ -0,0 +1,422 @@
+package ssl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/cli/genkey"
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/initca"
+	"github.com/cloudflare/cfssl/signer"
+	"github.com/cloudflare/cfssl/signer/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestKeyRequestDefaultsToRSA(t *testing.T) {
+	t.Parallel()
+
+	req := keyRequest("", 0)
+	assert.Equal(t, "rsa", req.A)
+	assert.Equal(t, 4096, req.S)
+}
+
+func TestKeyRequestECDSA(t *testing.T) {
+	t.Parallel()
+
+	req := keyRequest("ecdsa", 0)
+	assert.Equal(t, "ecdsa", req.A)
+	assert.Equal(t, 521, req.S)
+}
+
+func TestKeyRequestHonoursSize(t *testing.T) {
+	t.Parallel()
+
+	req := keyRequest("rsa", 2048)
+	assert.Equal(t, "rsa", req.A)
+	assert.Equal(t, 2048, req.S)
+}
+
+func TestUsageForProfile(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"server auth", "client auth"}, usageForProfile(""))
+	assert.Equal(t, []string{"server auth", "client auth"}, usageForProfile("both"))
+	assert.Equal(t, []string{"server auth"}, usageForProfile("server"))
+	assert.Equal(t, []string{"client auth"}, usageForProfile("client"))
+	assert.Equal(t, []string{"code signing"}, usageForProfile("code-signing"))
+	assert.Equal(t, []string{"ocsp signing"}, usageForProfile("ocsp-signing"))
+}
+
+func TestClientOnlyCertSkipsWildcarding(t *testing.T) {
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+		},
+		"client-cert": {
+			PrivateKeyName:  "client-key",
+			CertificateName: "client-cert",
+			RoleName:        "api-client",
+			Profile:         "client",
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	_, err := GenerateCerts(certInfo, "ns", "example.com", "svc.example.com", secrets)
+	require.NoError(t, err)
+
+	cert, err := helpers.ParseCertificatePEM(secrets.Data["client-cert"])
+	require.NoError(t, err)
+
+	for _, name := range cert.DNSNames {
+		assert.NotContains(t, name, "*", "client-only cert must not carry wildcard SANs")
+	}
+	assert.Len(t, cert.ExtKeyUsage, 1)
+}
+
+func TestIntermediateCASignsLeaves(t *testing.T) {
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+		},
+		"intermediate": {
+			PrivateKeyName:  "intermediate-key",
+			CertificateName: "intermediate-cert",
+			IsAuthority:     true,
+			CAName:          "cacert",
+		},
+		"leaf-root": {
+			PrivateKeyName:  "leaf-root-key",
+			CertificateName: "leaf-root-cert",
+			RoleName:        "leaf-root",
+		},
+		"leaf-intermediate": {
+			PrivateKeyName:  "leaf-intermediate-key",
+			CertificateName: "leaf-intermediate-cert",
+			RoleName:        "leaf-intermediate",
+			CAName:          "intermediate",
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	updated, err := GenerateCerts(certInfo, "ns", "example.com", "svc.example.com", secrets)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	rootCert, err := helpers.ParseCertificatePEM(secrets.Data["ca-cert"])
+	require.NoError(t, err)
+	intermediateCert, err := helpers.ParseCertificatePEM(secrets.Data["intermediate-cert"])
+	require.NoError(t, err)
+	leafRootCert, err := helpers.ParseCertificatePEM(secrets.Data["leaf-root-cert"])
+	require.NoError(t, err)
+	leafIntermediateCert, err := helpers.ParseCertificatePEM(secrets.Data["leaf-intermediate-cert"])
+	require.NoError(t, err)
+
+	assert.NoError(t, intermediateCert.CheckSignatureFrom(rootCert))
+	assert.NoError(t, leafRootCert.CheckSignatureFrom(rootCert))
+	assert.NoError(t, leafIntermediateCert.CheckSignatureFrom(intermediateCert))
+}
+
+func TestCreateCAHonoursExpiry(t *testing.T) {
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+			Expiry:          time.Hour,
+		},
+		"intermediate": {
+			PrivateKeyName:  "intermediate-key",
+			CertificateName: "intermediate-cert",
+			IsAuthority:     true,
+			CAName:          "cacert",
+			Expiry:          time.Hour,
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	updated, err := GenerateCerts(certInfo, "ns", "example.com", "svc.example.com", secrets)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	rootCert, err := helpers.ParseCertificatePEM(secrets.Data["ca-cert"])
+	require.NoError(t, err)
+	assert.True(t, time.Until(rootCert.NotAfter) <= time.Hour, "root CA must honour its configured Expiry, not the 30-year default")
+
+	intermediateCert, err := helpers.ParseCertificatePEM(secrets.Data["intermediate-cert"])
+	require.NoError(t, err)
+	assert.True(t, time.Until(intermediateCert.NotAfter) <= time.Hour, "intermediate CA must honour its configured Expiry, not the 30-year default")
+}
+
+func TestCircularCAReferenceIsRejected(t *testing.T) {
+	certInfo := map[string]CertInfo{
+		"a": {PrivateKeyName: "a-key", CertificateName: "a-cert", IsAuthority: true, CAName: "b"},
+		"b": {PrivateKeyName: "b-key", CertificateName: "b-cert", IsAuthority: true, CAName: "a"},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	_, err := GenerateCerts(certInfo, "ns", "example.com", "svc.example.com", secrets)
+	require.Error(t, err)
+}
+
+func TestUnknownCANameIsRejected(t *testing.T) {
+	certInfo := map[string]CertInfo{
+		"leaf": {PrivateKeyName: "leaf-key", CertificateName: "leaf-cert", RoleName: "leaf", CAName: "does-not-exist"},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+
+	_, err := GenerateCerts(certInfo, "ns", "example.com", "svc.example.com", secrets)
+	require.Error(t, err)
+}
+
+func TestNeedsRenewalReportsExpiringCert(t *testing.T) {
+	certPEM, _, _, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "1s"},
+		CN:         "test",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	renew, err := needsRenewal(certPEM, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, renew)
+}
+
+func TestNeedsRenewalFalseForFreshCert(t *testing.T) {
+	certPEM, _, _, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "262800h"},
+		CN:         "test",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	renew, err := needsRenewal(certPEM, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, renew)
+}
+
+func TestRenewBeforeThresholdUsesEnvVar(t *testing.T) {
+	t.Setenv(renewBeforeEnvVar, "2h")
+	assert.Equal(t, 2*time.Hour, renewBeforeThreshold())
+}
+
+func TestRenewBeforeThresholdDefaultsWhenUnset(t *testing.T) {
+	t.Setenv(renewBeforeEnvVar, "")
+	assert.Equal(t, defaultRenewBefore, renewBeforeThreshold())
+}
+
+func TestRenewBeforeThresholdDefaultsWhenInvalid(t *testing.T) {
+	t.Setenv(renewBeforeEnvVar, "not-a-duration")
+	assert.Equal(t, defaultRenewBefore, renewBeforeThreshold())
+}
+
+func TestRenewCAPreservesKeyAndRecordsPrevious(t *testing.T) {
+	certPEM, _, keyPEM, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "1s"},
+		CN:         "SCF CA",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{
+		"ca-key":  keyPEM,
+		"ca-cert": certPEM,
+	}}
+
+	updated, err := createCA(certInfo, secrets, "cacert", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	assert.Equal(t, keyPEM, secrets.Data["ca-key"], "CA private key must be preserved across renewal")
+	assert.NotEqual(t, certPEM, secrets.Data["ca-cert"], "CA certificate must be replaced on renewal")
+	assert.Equal(t, certPEM, secrets.Data["ca-cert-previous"], "previous CA certificate must be recorded")
+
+	newCert, err := helpers.ParseCertificatePEM(secrets.Data["ca-cert"])
+	require.NoError(t, err)
+	assert.True(t, time.Until(newCert.NotAfter) > time.Hour, "renewed CA certificate must have a fresh expiry")
+}
+
+func TestRenewCAHonoursExpiry(t *testing.T) {
+	certPEM, _, keyPEM, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "1s"},
+		CN:         "SCF CA",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+			Expiry:          time.Hour,
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{
+		"ca-key":  keyPEM,
+		"ca-cert": certPEM,
+	}}
+
+	updated, err := createCA(certInfo, secrets, "cacert", 2*time.Hour)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	newCert, err := helpers.ParseCertificatePEM(secrets.Data["ca-cert"])
+	require.NoError(t, err)
+	assert.True(t, time.Until(newCert.NotAfter) <= time.Hour, "renewed CA certificate must honour its configured Expiry, not the 30-year default")
+}
+
+func TestRenewIntermediateCAPreservesChainOfTrust(t *testing.T) {
+	rootCert, _, rootKey, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "262800h"},
+		CN:         "SCF CA",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	g := &csr.Generator{Validator: genkey.Validator}
+	_, intermediateKeyPEM, err := g.ProcessRequest(&csr.CertificateRequest{
+		CN:         "SCF CA: intermediate",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	intermediateCert := signIntermediate(t, rootCert, rootKey, intermediateKeyPEM, "intermediate", "1s")
+	time.Sleep(1100 * time.Millisecond)
+
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+			PrivateKey:      rootKey,
+			Certificate:     rootCert,
+		},
+		"intermediate": {
+			PrivateKeyName:  "intermediate-key",
+			CertificateName: "intermediate-cert",
+			IsAuthority:     true,
+			CAName:          "cacert",
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{
+		"ca-key":            rootKey,
+		"ca-cert":           rootCert,
+		"intermediate-key":  intermediateKeyPEM,
+		"intermediate-cert": intermediateCert,
+	}}
+
+	updated, err := createCA(certInfo, secrets, "intermediate", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	assert.Equal(t, intermediateKeyPEM, secrets.Data["intermediate-key"], "intermediate CA private key must be preserved across renewal")
+	assert.Equal(t, intermediateCert, secrets.Data["intermediate-cert-previous"], "previous intermediate CA certificate must be recorded")
+
+	renewedCert, err := helpers.ParseCertificatePEM(secrets.Data["intermediate-cert"])
+	require.NoError(t, err)
+	parsedRootCert, err := helpers.ParseCertificatePEM(rootCert)
+	require.NoError(t, err)
+	assert.NoError(t, renewedCert.CheckSignatureFrom(parsedRootCert), "renewed intermediate CA must still chain to its parent, not be self-signed")
+}
+
+// signIntermediate signs keyPEM's public key as an intermediate CA under
+// rootCert/rootKey with the given expiry, without going through
+// createIntermediateCA (which always mints a fresh key).
+func signIntermediate(t *testing.T, rootCert, rootKey, keyPEM []byte, cn, expiry string) []byte {
+	t.Helper()
+
+	caKey, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	require.NoError(t, err)
+	signingReq, err := csr.Generate(caKey, &csr.CertificateRequest{CN: fmt.Sprintf("SCF CA: %s", cn)})
+	require.NoError(t, err)
+
+	parentCert, err := helpers.ParseCertificatePEM(rootCert)
+	require.NoError(t, err)
+	parentKey, err := helpers.ParsePrivateKeyPEM(rootKey)
+	require.NoError(t, err)
+
+	expiryDuration, err := time.ParseDuration(expiry)
+	require.NoError(t, err)
+
+	policy := &config.Signing{
+		Profiles: map[string]*config.SigningProfile{},
+		Default: &config.SigningProfile{
+			Usage:        []string{"cert sign", "crl sign"},
+			Expiry:       expiryDuration,
+			ExpiryString: expiry,
+			CAConstraint: config.CAConstraint{IsCA: true},
+		},
+	}
+	s, err := local.NewSigner(parentKey, parentCert, signer.DefaultSigAlgo(parentKey), policy)
+	require.NoError(t, err)
+
+	cert, err := s.Sign(signer.SignRequest{Request: string(signingReq)})
+	require.NoError(t, err)
+	return cert
+}
+
+func TestRenewLeafCertRecordsPrevious(t *testing.T) {
+	caCert, _, caKey, err := initca.New(&csr.CertificateRequest{
+		CA:         &csr.CAConfig{Expiry: "262800h"},
+		CN:         "SCF CA",
+		KeyRequest: keyRequest("", 0),
+	})
+	require.NoError(t, err)
+
+	certInfo := map[string]CertInfo{
+		"cacert": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+			PrivateKey:      caKey,
+			Certificate:     caCert,
+		},
+		"leaf": {
+			PrivateKeyName:  "leaf-key",
+			CertificateName: "leaf-cert",
+			RoleName:        "leaf",
+			Expiry:          time.Second,
+		},
+	}
+	secrets := &v1.Secret{Data: map[string][]byte{
+		"ca-key":  caKey,
+		"ca-cert": caCert,
+	}}
+
+	_, err = createCert(certInfo, "ns", "example.com", "svc.example.com", secrets, "leaf", time.Hour)
+	require.NoError(t, err)
+	firstCert := secrets.Data["leaf-cert"]
+	require.NotEmpty(t, firstCert)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	updated, err := createCert(certInfo, "ns", "example.com", "svc.example.com", secrets, "leaf", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	assert.Equal(t, firstCert, secrets.Data["leaf-cert-previous"], "previous leaf certificate must be recorded")
+	assert.NotEqual(t, firstCert, secrets.Data["leaf-cert"], "leaf certificate must be replaced on renewal")
+}
@@ -0,0 +1,15 @@
+package store
+
+import "flag"
+
+// StoreFileFlagName is the conventional flag name a command line should
+// use to expose the store file path.
+const StoreFileFlagName = "store-file"
+
+// RegisterStoreFileFlag registers a --store-file flag on fs (typically
+// flag.CommandLine), returning a pointer to its value. An empty value
+// means no store file was given, and callers should fall back to
+// generating directly against a *v1.Secret, as before.
+func RegisterStoreFileFlag(fs *flag.FlagSet) *string {
+	return fs.String(StoreFileFlagName, "", "path to a JSON file used to persist generated secret material across runs, so the same manifest can be rendered deterministically outside a cluster")
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterStoreFileFlagDefaultsEmpty(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	path := RegisterStoreFileFlag(fs)
+	require := assert.New(t)
+	require.Equal("", *path)
+
+	require.NoError(fs.Parse([]string{"--store-file", "/tmp/secrets.json"}))
+	require.Equal("/tmp/secrets.json", *path)
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"github.com/SUSE/scf-secret-generator/password"
+	"github.com/SUSE/scf-secret-generator/ssh"
+	"github.com/SUSE/scf-secret-generator/ssl"
+	"github.com/SUSE/scf-secret-generator/util"
+
+	"k8s.io/api/core/v1"
+)
+
+// GeneratePassword generates a password for name, consulting the store
+// first so repeated runs against the same store are deterministic, and
+// recording a newly generated password back into the store. It returns
+// true if a new password was generated.
+func (s *Store) GeneratePassword(name string) bool {
+	secretData := map[string][]byte{}
+	if value, ok := s.Password(name); ok {
+		secretData[name] = []byte(value)
+	}
+	updateData := map[string][]byte{}
+
+	if !password.GeneratePassword(secretData, updateData, name) {
+		return false
+	}
+
+	s.SetPassword(name, string(updateData[name]))
+	return true
+}
+
+// GenerateSSHKeys generates every SSH CA, signed certificate, and plain
+// keypair in keys, consulting the store first, and recording anything
+// newly generated back into the store. It returns true if anything was
+// created or signed.
+func (s *Store) GenerateSSHKeys(keys map[string]ssh.Key, namespace, serviceDomainSuffix string) (bool, error) {
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+	for id, key := range keys {
+		s.populateSSHKey(secrets, id, key)
+	}
+
+	updated, err := ssh.GenerateCerts(keys, namespace, serviceDomainSuffix, secrets)
+	if err != nil {
+		return false, err
+	}
+
+	for id, key := range keys {
+		s.recordSSHKey(secrets, id, key)
+	}
+	return updated, nil
+}
+
+// populateSSHKey pre-populates secrets with whatever the store already has
+// on record for id, so ssh.GenerateCerts recognizes it as already
+// generated instead of creating it again.
+func (s *Store) populateSSHKey(secrets *v1.Secret, id string, key ssh.Key) {
+	var kc KeyCert
+	var ok bool
+	if key.IsAuthority {
+		var ca CA
+		ca, ok = s.CA(id)
+		kc = caAsKeyCert(ca)
+	} else {
+		kc, ok = s.KeyCert(key.CAName, id)
+	}
+	if !ok {
+		return
+	}
+
+	putIfNamed(secrets, kc.PrivateKeyName, kc.PrivateKey)
+	putIfNamed(secrets, kc.FingerprintName, kc.Fingerprint)
+	putIfNamed(secrets, kc.PublicKeyName, kc.PublicKey)
+	putIfNamed(secrets, kc.CertificateName, kc.Certificate)
+}
+
+// recordSSHKey copies anything ssh.GenerateCerts generated for id out of
+// secrets and into the store, under the real secret data key names so the
+// store round-trips correctly on its own.
+func (s *Store) recordSSHKey(secrets *v1.Secret, id string, key ssh.Key) {
+	kc := KeyCert{
+		PrivateKeyName:  util.ConvertNameToKey(key.PrivateKey),
+		FingerprintName: util.ConvertNameToKey(key.Fingerprint),
+		PublicKeyName:   util.ConvertNameToKey(key.PublicKey),
+		CertificateName: util.ConvertNameToKey(key.Certificate),
+	}
+	kc.PrivateKey = string(secrets.Data[kc.PrivateKeyName])
+	kc.Fingerprint = string(secrets.Data[kc.FingerprintName])
+	kc.PublicKey = string(secrets.Data[kc.PublicKeyName])
+	kc.Certificate = string(secrets.Data[kc.CertificateName])
+
+	if kc.PrivateKey == "" {
+		return
+	}
+
+	if key.IsAuthority {
+		ca := keyCertAsCA(kc)
+		if existing, ok := s.CA(id); ok && existing == ca {
+			return
+		}
+		s.SetCA(id, ca)
+	} else {
+		if existing, ok := s.KeyCert(key.CAName, id); ok && existing == kc {
+			return
+		}
+		s.SetKeyCert(key.CAName, id, kc)
+	}
+}
+
+// GenerateSSLCerts generates every SSL CA and certificate in certInfo,
+// consulting the store first, and recording anything newly generated or
+// renewed back into the store. It returns true if anything was created or
+// renewed.
+func (s *Store) GenerateSSLCerts(certInfo map[string]ssl.CertInfo, namespace, domain, serviceDomainSuffix string) (bool, error) {
+	secrets := &v1.Secret{Data: map[string][]byte{}}
+	for id, info := range certInfo {
+		s.populateSSLCert(secrets, id, info)
+	}
+
+	updated, err := ssl.GenerateCerts(certInfo, namespace, domain, serviceDomainSuffix, secrets)
+	if err != nil {
+		return false, err
+	}
+
+	for id, info := range certInfo {
+		s.recordSSLCert(secrets, id, info)
+	}
+	return updated, nil
+}
+
+func (s *Store) populateSSLCert(secrets *v1.Secret, id string, info ssl.CertInfo) {
+	var kc KeyCert
+	var ok bool
+	if info.IsAuthority {
+		var ca CA
+		ca, ok = s.CA(id)
+		kc = caAsKeyCert(ca)
+	} else {
+		kc, ok = s.KeyCert(info.CAName, id)
+	}
+	if !ok {
+		return
+	}
+
+	putIfNamed(secrets, kc.PrivateKeyName, kc.PrivateKey)
+	putIfNamed(secrets, kc.CertificateName, kc.Certificate)
+	putIfNamed(secrets, kc.CertificateName+ssl.PreviousSuffix, kc.PreviousCertificate)
+}
+
+func (s *Store) recordSSLCert(secrets *v1.Secret, id string, info ssl.CertInfo) {
+	kc := KeyCert{
+		PrivateKeyName:  info.PrivateKeyName,
+		CertificateName: info.CertificateName,
+	}
+	kc.PrivateKey = string(secrets.Data[kc.PrivateKeyName])
+	kc.Certificate = string(secrets.Data[kc.CertificateName])
+	kc.PreviousCertificate = string(secrets.Data[kc.CertificateName+ssl.PreviousSuffix])
+
+	if kc.PrivateKey == "" {
+		return
+	}
+
+	if info.IsAuthority {
+		ca := keyCertAsCA(kc)
+		if existing, ok := s.CA(id); ok && existing == ca {
+			return
+		}
+		s.SetCA(id, ca)
+	} else {
+		if existing, ok := s.KeyCert(info.CAName, id); ok && existing == kc {
+			return
+		}
+		s.SetKeyCert(info.CAName, id, kc)
+	}
+}
+
+// putIfNamed sets secrets.Data[name] = value, unless name or value is
+// empty.
+func putIfNamed(secrets *v1.Secret, name, value string) {
+	if name == "" || value == "" {
+		return
+	}
+	secrets.Data[name] = []byte(value)
+}
+
+// caAsKeyCert adapts a CA to the KeyCert shape used by the populate/record
+// helpers above, which are shared between CAs and leaf certs/keypairs.
+func caAsKeyCert(ca CA) KeyCert {
+	return KeyCert{
+		PrivateKeyName:      ca.PrivateKeyName,
+		PrivateKey:          ca.PrivateKey,
+		CertificateName:     ca.CertificateName,
+		Certificate:         ca.Certificate,
+		PreviousCertificate: ca.PreviousCertificate,
+		FingerprintName:     ca.FingerprintName,
+		Fingerprint:         ca.Fingerprint,
+	}
+}
+
+// keyCertAsCA is the inverse of caAsKeyCert. PublicKey fields don't apply
+// to CAs and are dropped.
+func keyCertAsCA(kc KeyCert) CA {
+	return CA{
+		PrivateKeyName:      kc.PrivateKeyName,
+		PrivateKey:          kc.PrivateKey,
+		CertificateName:     kc.CertificateName,
+		Certificate:         kc.Certificate,
+		PreviousCertificate: kc.PreviousCertificate,
+		FingerprintName:     kc.FingerprintName,
+		Fingerprint:         kc.Fingerprint,
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SUSE/scf-secret-generator/ssh"
+	"github.com/SUSE/scf-secret-generator/ssl"
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/initca"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePasswordIsDeterministicAcrossRuns(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+
+	assert.True(t, s.GeneratePassword("foo"))
+	value, ok := s.Password("foo")
+	require.True(t, ok)
+
+	assert.False(t, s.GeneratePassword("foo"))
+	again, _ := s.Password("foo")
+	assert.Equal(t, value, again)
+}
+
+func TestGenerateSSHKeysRecordsEveryEntry(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+
+	keys := map[string]ssh.Key{
+		"ca": {
+			PrivateKey:  "ca-key",
+			Fingerprint: "ca-fingerprint",
+			IsAuthority: true,
+		},
+		"host": {
+			PrivateKey:  "host-key",
+			Fingerprint: "host-fingerprint",
+			PublicKey:   "host-pub",
+			Certificate: "host-cert",
+			CAName:      "ca",
+			RoleName:    "api",
+		},
+		"plain": {
+			PrivateKey:  "plain-key",
+			Fingerprint: "plain-fingerprint",
+		},
+	}
+
+	updated, err := s.GenerateSSHKeys(keys, "ns", "svc.example.com")
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	ca, ok := s.CA("ca")
+	require.True(t, ok)
+	assert.NotEmpty(t, ca.PrivateKey)
+	assert.Equal(t, "ca-key", ca.PrivateKeyName)
+
+	host, ok := s.KeyCert("ca", "host")
+	require.True(t, ok)
+	assert.NotEmpty(t, host.Certificate)
+	assert.Equal(t, "host-cert", host.CertificateName)
+
+	plain, ok := s.KeyCert("", "plain")
+	require.True(t, ok)
+	assert.NotEmpty(t, plain.PrivateKey)
+	assert.Empty(t, plain.Certificate)
+
+	require.NoError(t, s.Save())
+
+	// a second run should find everything already recorded and change nothing
+	updated, err = s.GenerateSSHKeys(keys, "ns", "svc.example.com")
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.False(t, s.Changed(), "re-recording identical keys must not mark the store changed")
+}
+
+func TestGenerateSSLCertsRecordsEveryEntry(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+
+	certInfo := map[string]ssl.CertInfo{
+		"ca": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+		},
+		"leaf": {
+			PrivateKeyName:  "leaf-key",
+			CertificateName: "leaf-cert",
+			RoleName:        "api",
+			CAName:          "ca",
+		},
+	}
+
+	updated, err := s.GenerateSSLCerts(certInfo, "ns", "example.com", "svc.example.com")
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	ca, ok := s.CA("ca")
+	require.True(t, ok)
+	assert.NotEmpty(t, ca.Certificate)
+
+	leaf, ok := s.KeyCert("ca", "leaf")
+	require.True(t, ok)
+	assert.NotEmpty(t, leaf.Certificate)
+
+	require.NoError(t, s.Save())
+
+	updated, err = s.GenerateSSLCerts(certInfo, "ns", "example.com", "svc.example.com")
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.False(t, s.Changed(), "re-recording identical certs must not mark the store changed")
+}
+
+// TestGenerateSSLCertsRoundTripsPreviousCertificate verifies that the
+// overlap-window "previous" certificate a renewal records (see
+// ssl.PreviousSuffix) survives being stored to and read back from the
+// JSON store, not just a live *v1.Secret.
+func TestGenerateSSLCertsRoundTripsPreviousCertificate(t *testing.T) {
+	certPEM, _, keyPEM, err := initca.New(&csr.CertificateRequest{
+		CA: &csr.CAConfig{Expiry: "1s"},
+		CN: "SCF CA",
+	})
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+	s.SetCA("ca", CA{
+		PrivateKeyName:  "ca-key",
+		PrivateKey:      string(keyPEM),
+		CertificateName: "ca-cert",
+		Certificate:     string(certPEM),
+	})
+
+	certInfo := map[string]ssl.CertInfo{
+		"ca": {
+			PrivateKeyName:  "ca-key",
+			CertificateName: "ca-cert",
+			IsAuthority:     true,
+		},
+	}
+
+	updated, err := s.GenerateSSLCerts(certInfo, "ns", "example.com", "svc.example.com")
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	ca, ok := s.CA("ca")
+	require.True(t, ok)
+	assert.NotEqual(t, string(certPEM), ca.Certificate, "CA certificate must be replaced on renewal")
+	assert.Equal(t, string(certPEM), ca.PreviousCertificate, "previous CA certificate must round-trip through the store")
+}
@@ -0,0 +1,264 @@
+// Package store persists generated secret material (passwords, CAs, leaf
+// certs, and SSH keys) to a JSON file on disk, keyed by cluster and
+// namespace, so the same values can be rendered deterministically outside
+// a running cluster (CI, air-gapped bootstrap, or template rendering) and
+// later imported into Kubernetes as a *v1.Secret.
+//
+// GeneratePassword, GenerateSSHKeys, and GenerateSSLCerts (see
+// generate.go) wrap the password/ssh/ssl packages' own generators,
+// consulting the store first so repeated runs against the same store are
+// deterministic, and recording anything newly generated back into the
+// store. Save is a no-op unless Changed reports true, so a --store-file
+// on disk is only rewritten when new material was actually generated.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"k8s.io/api/core/v1"
+)
+
+// schemaVersion is bumped whenever the on-disk format changes in a way
+// that isn't simply additive, so future releases can migrate old files.
+const schemaVersion = 1
+
+// file is the root of the on-disk JSON document.
+type file struct {
+	Version  int                        `json:"version"`
+	Clusters map[string]*clusterSecrets `json:"clusters"`
+}
+
+// clusterSecrets holds every generated secret for one cluster/namespace.
+type clusterSecrets struct {
+	Passwords map[string]string  `json:"passwords,omitempty"`
+	CAs       map[string]CA      `json:"cas,omitempty"`
+	KeyCerts  map[string]KeyCert `json:"key_certs,omitempty"`
+	Tokens    map[string]string  `json:"tokens,omitempty"`
+}
+
+// CA is a CA keypair, PEM encoded, recorded alongside the actual secret
+// data key names (ssl.CertInfo.PrivateKeyName/CertificateName, or their
+// ssh.Key equivalents) it was generated under, so it can be restored into
+// a *v1.Secret the generators will recognize.
+type CA struct {
+	PrivateKeyName  string `json:"private_key_name,omitempty"`
+	PrivateKey      string `json:"private_key,omitempty"`
+	CertificateName string `json:"certificate_name,omitempty"`
+	Certificate     string `json:"certificate,omitempty"`
+
+	// PreviousCertificate is the certificate Certificate most recently
+	// replaced on renewal, recorded under CertificateName+ssl.PreviousSuffix
+	// so consumers can trust both during the rollover window.
+	PreviousCertificate string `json:"previous_certificate,omitempty"`
+
+	FingerprintName string `json:"fingerprint_name,omitempty"`
+	Fingerprint     string `json:"fingerprint,omitempty"`
+}
+
+// KeyCert is a leaf certificate or SSH keypair/certificate, PEM/blob
+// encoded, recorded alongside the actual secret data key names it was
+// generated under. Fields that don't apply to a given entry (e.g.
+// Certificate for a plain, unsigned SSH keypair) are left empty.
+type KeyCert struct {
+	PrivateKeyName  string `json:"private_key_name,omitempty"`
+	PrivateKey      string `json:"private_key,omitempty"`
+	CertificateName string `json:"certificate_name,omitempty"`
+	Certificate     string `json:"certificate,omitempty"`
+
+	// PreviousCertificate is the certificate Certificate most recently
+	// replaced on renewal, recorded under CertificateName+ssl.PreviousSuffix
+	// so consumers can trust both during the rollover window.
+	PreviousCertificate string `json:"previous_certificate,omitempty"`
+
+	FingerprintName string `json:"fingerprint_name,omitempty"`
+	Fingerprint     string `json:"fingerprint,omitempty"`
+	PublicKeyName   string `json:"public_key_name,omitempty"`
+	PublicKey       string `json:"public_key,omitempty"`
+}
+
+// Store is a JSON-file-backed secret store scoped to a single
+// cluster/namespace.
+type Store struct {
+	path    string
+	cluster string
+	data    *file
+	changed bool
+}
+
+// Load reads path, or starts an empty store if it doesn't exist yet, and
+// scopes it to cluster/namespace.
+func Load(path, cluster, namespace string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		cluster: cluster + "/" + namespace,
+		data:    &file{Version: schemaVersion, Clusters: map[string]*clusterSecrets{}},
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("Cannot read store file %s: %s", path, err)
+	}
+
+	if err := json.Unmarshal(raw, s.data); err != nil {
+		return nil, fmt.Errorf("Cannot parse store file %s: %s", path, err)
+	}
+	if s.data.Clusters == nil {
+		s.data.Clusters = map[string]*clusterSecrets{}
+	}
+	return s, nil
+}
+
+func (s *Store) entry() *clusterSecrets {
+	c, ok := s.data.Clusters[s.cluster]
+	if !ok {
+		c = &clusterSecrets{}
+		s.data.Clusters[s.cluster] = c
+	}
+	return c
+}
+
+// Password returns the recorded password, if any.
+func (s *Store) Password(name string) (string, bool) {
+	value, ok := s.entry().Passwords[name]
+	return value, ok
+}
+
+// SetPassword records a generated password.
+func (s *Store) SetPassword(name, value string) {
+	c := s.entry()
+	if c.Passwords == nil {
+		c.Passwords = map[string]string{}
+	}
+	c.Passwords[name] = value
+	s.changed = true
+}
+
+// CA returns the recorded CA, if any.
+func (s *Store) CA(id string) (CA, bool) {
+	value, ok := s.entry().CAs[id]
+	return value, ok
+}
+
+// SetCA records a generated or renewed CA under id (the same generator ID
+// used by ssl.CertInfo / ssh.Key).
+func (s *Store) SetCA(id string, ca CA) {
+	c := s.entry()
+	if c.CAs == nil {
+		c.CAs = map[string]CA{}
+	}
+	c.CAs[id] = ca
+	s.changed = true
+}
+
+// KeyCert returns the recorded leaf cert or SSH keypair signed by caName
+// (empty for one that isn't signed by any CA), if any.
+func (s *Store) KeyCert(caName, id string) (KeyCert, bool) {
+	value, ok := s.entry().KeyCerts[keyCertKey(caName, id)]
+	return value, ok
+}
+
+// SetKeyCert records a generated leaf cert or SSH keypair, under id (the
+// same generator ID used by ssl.CertInfo / ssh.Key), signed by caName
+// (empty if it isn't signed by any CA).
+func (s *Store) SetKeyCert(caName, id string, kc KeyCert) {
+	c := s.entry()
+	if c.KeyCerts == nil {
+		c.KeyCerts = map[string]KeyCert{}
+	}
+	c.KeyCerts[keyCertKey(caName, id)] = kc
+	s.changed = true
+}
+
+// keyCertKey is only ever used as a Go map key into this store's own
+// in-memory/JSON state, never as a Kubernetes secret data key, so the "/"
+// separator (illegal in the latter) is fine here.
+func keyCertKey(caName, id string) string {
+	return caName + "/" + id
+}
+
+// Token returns the recorded token, if any.
+func (s *Store) Token(name string) (string, bool) {
+	value, ok := s.entry().Tokens[name]
+	return value, ok
+}
+
+// SetToken records a generated token.
+func (s *Store) SetToken(name, value string) {
+	c := s.entry()
+	if c.Tokens == nil {
+		c.Tokens = map[string]string{}
+	}
+	c.Tokens[name] = value
+	s.changed = true
+}
+
+// Changed reports whether anything has been recorded since the store was
+// loaded (or since the last Save).
+func (s *Store) Changed() bool {
+	return s.changed
+}
+
+// Save rewrites the store file, unless nothing has changed.
+func (s *Store) Save() error {
+	if !s.changed {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot marshal store: %s", err)
+	}
+	if err := ioutil.WriteFile(s.path, raw, 0600); err != nil {
+		return fmt.Errorf("Cannot write store file %s: %s", s.path, err)
+	}
+	s.changed = false
+	return nil
+}
+
+// AsSecret builds a *v1.Secret pre-populated with every password and
+// token this store already has on record for the store's
+// cluster/namespace. CAs and leaf certs/keypairs carry their own secret
+// data key names and are handled by GenerateSSLCerts/GenerateSSHKeys instead, so
+// they round-trip correctly even though the manifest-derived names
+// they're stored under aren't known to the store ahead of generation.
+func (s *Store) AsSecret() *v1.Secret {
+	secret := &v1.Secret{Data: map[string][]byte{}}
+	c := s.entry()
+
+	for name, value := range c.Passwords {
+		secret.Data[name] = []byte(value)
+	}
+	for name, value := range c.Tokens {
+		secret.Data[name] = []byte(value)
+	}
+	return secret
+}
+
+// Import copies every entry of secret that isn't already recorded into
+// the store as a password entry, and marks the store changed if it added
+// anything. It only ever populates Passwords: secret's flat data map
+// gives no way to tell a CA key, leaf cert, or SSH key half apart from a
+// plain password, so importing a secret containing previously
+// materialized CA/cert/SSH material would flatten it under the wrong
+// keys. Callers seeding a store from such a secret must use SetCA/
+// SetKeyCert directly, under the same {caName, id} keys
+// GenerateSSLCerts/GenerateSSHKeys already do.
+func (s *Store) Import(secret *v1.Secret) {
+	c := s.entry()
+	for name, value := range secret.Data {
+		if _, ok := c.Passwords[name]; ok {
+			continue
+		}
+		if c.Passwords == nil {
+			c.Passwords = map[string]string{}
+		}
+		c.Passwords[name] = string(value)
+		s.changed = true
+	}
+}
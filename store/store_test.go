@@ -0,0 +1,106 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempStorePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "store-test")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "secrets.json")
+}
+
+func TestLoadMissingFileStartsEmpty(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+
+	_, ok := s.Password("foo")
+	assert.False(t, ok)
+	assert.False(t, s.Changed())
+}
+
+func TestSetPasswordMarksChanged(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+
+	s.SetPassword("foo", "bar")
+	assert.True(t, s.Changed())
+
+	value, ok := s.Password("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", value)
+}
+
+func TestSaveIsNoopWhenUnchanged(t *testing.T) {
+	path := tempStorePath(t)
+	s, err := Load(path, "cluster", "ns")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Save())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSaveAndReloadRoundTrips(t *testing.T) {
+	path := tempStorePath(t)
+	s, err := Load(path, "cluster", "ns")
+	require.NoError(t, err)
+
+	s.SetPassword("foo", "bar")
+	s.SetCA("cacert", CA{Certificate: "cert-pem", PrivateKey: "key-pem"})
+	s.SetKeyCert("cacert", "leaf", KeyCert{Certificate: "leaf-cert-pem", PrivateKey: "leaf-key-pem"})
+	require.NoError(t, s.Save())
+	assert.False(t, s.Changed())
+
+	reloaded, err := Load(path, "cluster", "ns")
+	require.NoError(t, err)
+
+	value, ok := reloaded.Password("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", value)
+
+	ca, ok := reloaded.CA("cacert")
+	assert.True(t, ok)
+	assert.Equal(t, "cert-pem", ca.Certificate)
+
+	kc, ok := reloaded.KeyCert("cacert", "leaf")
+	assert.True(t, ok)
+	assert.Equal(t, "leaf-cert-pem", kc.Certificate)
+}
+
+func TestDifferentNamespacesAreIsolated(t *testing.T) {
+	path := tempStorePath(t)
+	s1, err := Load(path, "cluster", "ns1")
+	require.NoError(t, err)
+	s1.SetPassword("foo", "ns1-value")
+	require.NoError(t, s1.Save())
+
+	s2, err := Load(path, "cluster", "ns2")
+	require.NoError(t, err)
+	_, ok := s2.Password("foo")
+	assert.False(t, ok)
+}
+
+func TestAsSecretAndImport(t *testing.T) {
+	s, err := Load(tempStorePath(t), "cluster", "ns")
+	require.NoError(t, err)
+	s.SetPassword("foo", "bar")
+
+	secret := s.AsSecret()
+	assert.Equal(t, "bar", string(secret.Data["foo"]))
+
+	secret.Data["new-password"] = []byte("generated")
+	s.Import(secret)
+
+	value, ok := s.Password("new-password")
+	assert.True(t, ok)
+	assert.Equal(t, "generated", value)
+	assert.True(t, s.Changed())
+}
@@ -0,0 +1,10 @@
+package util
+
+import "strings"
+
+// ConvertNameToKey converts a manifest variable name (conventionally
+// UPPER_SNAKE_CASE, as used for environment variables) into a key that is
+// valid for use in a Kubernetes secret (lowercase, dash-separated).
+func ConvertNameToKey(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "-", -1))
+}